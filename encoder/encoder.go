@@ -0,0 +1,50 @@
+// Package encoder provides on-the-fly PCM to compressed-audio encoding
+// so the server can transcode WAV/FLAC/PCM sources to the format a
+// client asked for instead of only relaying raw file bytes.
+package encoder
+
+import "fmt"
+
+// Encoder turns interleaved 16-bit PCM samples into compressed audio
+// frames suitable for streaming to a client.
+type Encoder interface {
+	// Encode compresses a block of interleaved PCM samples. It may
+	// buffer samples internally and return no bytes until it has
+	// enough for a full frame.
+	Encode(pcm []int16) ([]byte, error)
+
+	// Close flushes any buffered samples, returning the final
+	// compressed bytes (if any), and releases the underlying codec
+	// resources. The encoder must not be used afterwards.
+	Close() ([]byte, error)
+}
+
+// Format identifies the output codec requested by a client.
+type Format string
+
+const (
+	FormatMP3  Format = "mp3"
+	FormatOpus Format = "opus"
+)
+
+// Options configures a new Encoder.
+type Options struct {
+	// SampleRate is the input PCM sample rate in Hz.
+	SampleRate int
+	// Channels is the input channel count (1 or 2).
+	Channels int
+	// BitrateKbps is the target output bitrate in kbit/s.
+	BitrateKbps int
+}
+
+// New builds the Encoder for the requested output format.
+func New(format Format, opts Options) (Encoder, error) {
+	switch format {
+	case FormatMP3:
+		return newLameEncoder(opts)
+	case FormatOpus:
+		return newOpusEncoder(opts)
+	default:
+		return nil, fmt.Errorf("encoder: unsupported format %q", format)
+	}
+}
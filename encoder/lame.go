@@ -0,0 +1,87 @@
+package encoder
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/viert/lame"
+)
+
+// lameEncoder implements Encoder using go-lame, upmixing mono input
+// to stereo before handing samples to the MP3 encoder. It encodes at
+// the source's own sample rate; github.com/viert/lame never binds
+// lame_set_out_samplerate, so output-rate resampling isn't available
+// through this library and must happen upstream (if ever needed)
+// before samples reach Encode.
+type lameEncoder struct {
+	opts Options
+	buf  bytes.Buffer
+	w    *lame.Writer
+}
+
+func newLameEncoder(opts Options) (Encoder, error) {
+	if opts.Channels != 1 && opts.Channels != 2 {
+		return nil, fmt.Errorf("encoder: lame requires 1 or 2 input channels, got %d", opts.Channels)
+	}
+
+	e := &lameEncoder{opts: opts}
+	e.w = lame.NewWriter(&e.buf)
+	e.w.Encoder.SetInSamplerate(opts.SampleRate)
+	e.w.Encoder.SetNumChannels(2)
+	e.w.Encoder.SetBitrate(opts.BitrateKbps)
+	e.w.Encoder.SetMode(lame.JOINT_STEREO)
+	e.w.Encoder.SetQuality(5)
+	if err := e.w.Encoder.InitParams(); err != nil {
+		return nil, fmt.Errorf("encoder: lame init failed: %w", err)
+	}
+
+	return e, nil
+}
+
+func (e *lameEncoder) Encode(pcm []int16) ([]byte, error) {
+	stereo := pcm
+	if e.opts.Channels == 1 {
+		stereo = upmixMonoToStereo(pcm)
+	}
+
+	raw := int16sToBytes(stereo)
+	e.buf.Reset()
+	if _, err := e.w.Write(raw); err != nil {
+		return nil, fmt.Errorf("encoder: lame write failed: %w", err)
+	}
+
+	out := make([]byte, e.buf.Len())
+	copy(out, e.buf.Bytes())
+	return out, nil
+}
+
+func (e *lameEncoder) Close() ([]byte, error) {
+	e.buf.Reset()
+	if err := e.w.Close(); err != nil {
+		return nil, fmt.Errorf("encoder: lame close failed: %w", err)
+	}
+
+	out := make([]byte, e.buf.Len())
+	copy(out, e.buf.Bytes())
+	return out, nil
+}
+
+// upmixMonoToStereo duplicates each mono sample across both channels.
+func upmixMonoToStereo(mono []int16) []int16 {
+	stereo := make([]int16, len(mono)*2)
+	for i, s := range mono {
+		stereo[2*i] = s
+		stereo[2*i+1] = s
+	}
+	return stereo
+}
+
+// int16sToBytes packs interleaved little-endian PCM samples into bytes.
+func int16sToBytes(samples []int16) []byte {
+	out := make([]byte, len(samples)*2)
+	for i, s := range samples {
+		out[2*i] = byte(s)
+		out[2*i+1] = byte(s >> 8)
+	}
+	return out
+}
@@ -0,0 +1,124 @@
+package encoder
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// oggCRCTable is the lookup table for the CRC-32 variant (polynomial
+// 0x04c11db7, no reflection) used to checksum Ogg pages.
+var oggCRCTable = buildOggCRCTable()
+
+func buildOggCRCTable() [256]uint32 {
+	var table [256]uint32
+	const poly = 0x04c11db7
+	for i := 0; i < 256; i++ {
+		crc := uint32(i) << 24
+		for bit := 0; bit < 8; bit++ {
+			if crc&0x80000000 != 0 {
+				crc = (crc << 1) ^ poly
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}
+
+func oggCRC(data []byte) uint32 {
+	var crc uint32
+	for _, b := range data {
+		crc = (crc << 8) ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	return crc
+}
+
+// oggStream writes Opus packets as a sequence of single-packet Ogg
+// pages, which is sufficient for a streaming (non-seekable) client.
+type oggStream struct {
+	serial     uint32
+	pageSeqNo  uint32
+	granulePos uint64
+	headerSent bool
+}
+
+func newOggStream(serial uint32) *oggStream {
+	return &oggStream{serial: serial}
+}
+
+// WriteOpusHeader emits the mandatory OpusHead and OpusTags pages
+// that must precede any audio data in an Ogg Opus stream.
+func (s *oggStream) WriteOpusHeader(sampleRate int, channels int) []byte {
+	head := new(bytes.Buffer)
+	head.WriteString("OpusHead")
+	head.WriteByte(1) // version
+	head.WriteByte(byte(channels))
+	binary.Write(head, binary.LittleEndian, uint16(0))          // pre-skip
+	binary.Write(head, binary.LittleEndian, uint32(sampleRate)) // input sample rate
+	binary.Write(head, binary.LittleEndian, int16(0))           // output gain
+	head.WriteByte(0)                                           // channel mapping family
+
+	tags := new(bytes.Buffer)
+	tags.WriteString("OpusTags")
+	vendor := "go-audio-streaming"
+	binary.Write(tags, binary.LittleEndian, uint32(len(vendor)))
+	tags.WriteString(vendor)
+	binary.Write(tags, binary.LittleEndian, uint32(0)) // no comments
+
+	out := new(bytes.Buffer)
+	out.Write(s.page(head.Bytes(), true, false))
+	out.Write(s.page(tags.Bytes(), false, false))
+	s.headerSent = true
+	return out.Bytes()
+}
+
+// WritePacket wraps a single Opus packet in its own Ogg page,
+// advancing the granule position by the packet's frame count.
+func (s *oggStream) WritePacket(packet []byte, frameSamples int, eos bool) []byte {
+	s.granulePos += uint64(frameSamples)
+	return s.page(packet, false, eos)
+}
+
+// page assembles a single-segment Ogg page around payload.
+func (s *oggStream) page(payload []byte, bos bool, eos bool) []byte {
+	var headerType byte
+	if bos {
+		headerType |= 0x02
+	}
+	if eos {
+		headerType |= 0x04
+	}
+
+	segments := segmentTable(len(payload))
+
+	header := new(bytes.Buffer)
+	header.WriteString("OggS")
+	header.WriteByte(0) // stream structure version
+	header.WriteByte(headerType)
+	binary.Write(header, binary.LittleEndian, s.granulePos)
+	binary.Write(header, binary.LittleEndian, s.serial)
+	binary.Write(header, binary.LittleEndian, s.pageSeqNo)
+	binary.Write(header, binary.LittleEndian, uint32(0)) // checksum placeholder
+	header.WriteByte(byte(len(segments)))
+	header.Write(segments)
+	header.Write(payload)
+
+	s.pageSeqNo++
+
+	raw := header.Bytes()
+	crc := oggCRC(raw)
+	binary.LittleEndian.PutUint32(raw[22:26], crc)
+	return raw
+}
+
+// segmentTable builds an Ogg lacing table for a payload of length n.
+func segmentTable(n int) []byte {
+	var segments []byte
+	for n >= 255 {
+		segments = append(segments, 255)
+		n -= 255
+	}
+	segments = append(segments, byte(n))
+	return segments
+}
@@ -0,0 +1,97 @@
+package encoder
+
+import (
+	"bytes"
+	"fmt"
+
+	"gopkg.in/hraban/opus.v2"
+)
+
+// opusFrameSamples is the Opus frame size in samples per channel
+// (20ms at 48kHz), the duration go-audio-streaming encodes with.
+const opusFrameSamples = 960
+
+// opusEncoder implements Encoder using hraban/opus, wrapping the
+// compressed packets in an Ogg Opus container as they're produced.
+type opusEncoder struct {
+	opts       Options
+	enc        *opus.Encoder
+	stream     *oggStream
+	headerSent bool
+
+	// pending holds interleaved PCM samples left over from the last
+	// Encode call that didn't fill a whole opusFrameSamples frame.
+	pending []int16
+}
+
+func newOpusEncoder(opts Options) (Encoder, error) {
+	if opts.SampleRate != 48000 {
+		return nil, fmt.Errorf("encoder: opus requires a 48000Hz source, got %d", opts.SampleRate)
+	}
+
+	enc, err := opus.NewEncoder(opts.SampleRate, opts.Channels, opus.AppAudio)
+	if err != nil {
+		return nil, fmt.Errorf("encoder: opus init failed: %w", err)
+	}
+	if err := enc.SetBitrate(opts.BitrateKbps * 1000); err != nil {
+		return nil, fmt.Errorf("encoder: opus set bitrate failed: %w", err)
+	}
+
+	return &opusEncoder{
+		opts:   opts,
+		enc:    enc,
+		stream: newOggStream(1),
+	}, nil
+}
+
+func (e *opusEncoder) Encode(pcm []int16) ([]byte, error) {
+	out := new(bytes.Buffer)
+	if !e.headerSent {
+		out.Write(e.stream.WriteOpusHeader(e.opts.SampleRate, e.opts.Channels))
+		e.headerSent = true
+	}
+
+	frameLen := opusFrameSamples * e.opts.Channels
+	packetBuf := make([]byte, 4000)
+
+	samples := append(e.pending, pcm...)
+	e.pending = nil
+
+	offset := 0
+	for ; offset+frameLen <= len(samples); offset += frameLen {
+		n, err := e.enc.Encode(samples[offset:offset+frameLen], packetBuf)
+		if err != nil {
+			return nil, fmt.Errorf("encoder: opus encode failed: %w", err)
+		}
+		out.Write(e.stream.WritePacket(packetBuf[:n], opusFrameSamples, false))
+	}
+
+	if offset < len(samples) {
+		e.pending = append([]int16(nil), samples[offset:]...)
+	}
+
+	return out.Bytes(), nil
+}
+
+// Close pads any leftover samples that never reached a full frame with
+// silence, encodes them as the final packet, and marks the Ogg stream
+// as ended. When no samples are pending, Close writes nothing, so the
+// stream then carries no end-of-stream page.
+func (e *opusEncoder) Close() ([]byte, error) {
+	if len(e.pending) == 0 {
+		return nil, nil
+	}
+
+	frameLen := opusFrameSamples * e.opts.Channels
+	final := make([]int16, frameLen)
+	copy(final, e.pending)
+	e.pending = nil
+
+	packetBuf := make([]byte, 4000)
+	n, err := e.enc.Encode(final, packetBuf)
+	if err != nil {
+		return nil, fmt.Errorf("encoder: opus flush failed: %w", err)
+	}
+
+	return e.stream.WritePacket(packetBuf[:n], opusFrameSamples, true), nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dangquyitt/go-audio-streaming/stream"
+)
+
+// fileProducer owns the single reader goroutine for one resource
+// file and fans its chunks out to every subscribed client.
+type fileProducer struct {
+	broadcaster *stream.Broadcaster
+	// status carries the single zero-length "end of file" marker via
+	// KeepLast, since only the latest (and only) value matters. The
+	// marker reaches only subscribers registered when it is published;
+	// p is evicted from producers first, so a client arriving later
+	// starts a fresh reader instead of hanging on a finished broadcaster.
+	status *stream.Broadcaster
+	stopCh chan struct{}
+	refs   int
+	done   bool
+}
+
+var (
+	producersMu sync.Mutex
+	producers   = make(map[string]*fileProducer)
+)
+
+// subscribeToFile joins the shared broadcast of filename, starting
+// its reader goroutine on first subscriber and stopping it once the
+// last subscriber unsubscribes. The returned channel yields a
+// zero-length Packet to signal end of file and is closed if the
+// client's ring buffer overflows.
+func subscribeToFile(filename string) (<-chan stream.Packet, func()) {
+	producersMu.Lock()
+	p, ok := producers[filename]
+	if !ok {
+		p = &fileProducer{
+			broadcaster: stream.NewBroadcaster(),
+			status:      stream.NewBroadcaster(),
+			stopCh:      make(chan struct{}),
+		}
+		producers[filename] = p
+		go runFileProducer(filename, p)
+	}
+	p.refs++
+
+	audio, unsubAudio := p.broadcaster.Subscribe(stream.KeepAll, stream.DefaultRingCapacity)
+	status, unsubStatus := p.status.Subscribe(stream.KeepLast, 1)
+	producersMu.Unlock()
+
+	out := make(chan stream.Packet, 1)
+	relayDone := make(chan struct{})
+	go relayFileSubscription(audio, status, out, relayDone)
+
+	release := func() {
+		close(relayDone)
+		unsubAudio()
+		unsubStatus()
+
+		producersMu.Lock()
+		p.refs--
+		if p.refs <= 0 && !p.done {
+			close(p.stopCh)
+			evictProducer(filename, p)
+		}
+		producersMu.Unlock()
+	}
+	return out, release
+}
+
+// relayFileSubscription merges a subscriber's audio chunks and its
+// one-shot end-of-file status into a single output channel, closing
+// out when either source closes or the subscriber releases.
+func relayFileSubscription(audio, status <-chan stream.Packet, out chan<- stream.Packet, done <-chan struct{}) {
+	defer close(out)
+
+	for {
+		select {
+		case pkt, ok := <-audio:
+			if !ok {
+				return
+			}
+			select {
+			case out <- pkt:
+			case <-done:
+				return
+			}
+
+		case pkt, ok := <-status:
+			if !ok {
+				return
+			}
+			select {
+			case out <- pkt:
+			case <-done:
+			}
+			return
+
+		case <-done:
+			return
+		}
+	}
+}
+
+// evictProducer removes p from producers if it's still the current
+// entry for filename. Must be called with producersMu held.
+func evictProducer(filename string, p *fileProducer) {
+	if producers[filename] == p {
+		delete(producers, filename)
+	}
+}
+
+// runFileProducer reads filename once and publishes each chunk to
+// every current subscriber until the file ends or the last
+// subscriber leaves.
+func runFileProducer(filename string, p *fileProducer) {
+	filePath := filepath.Join("resource", filename)
+	file, err := os.Open(filePath)
+	if err != nil {
+		log.Println("Error opening file for fan-out:", err)
+		finishProducer(filename, p)
+		return
+	}
+	defer file.Close()
+
+	buffer := make([]byte, 8192)
+
+	for {
+		select {
+		case <-p.stopCh:
+			return
+		default:
+		}
+
+		n, err := file.Read(buffer)
+		if n > 0 {
+			chunk := make([]byte, n)
+			copy(chunk, buffer[:n])
+			p.broadcaster.Publish(stream.Packet{Data: chunk})
+		}
+
+		if err == io.EOF {
+			finishProducerWithStatus(filename, p, stream.Packet{})
+			return
+		}
+		if err != nil {
+			log.Println("Error reading file for fan-out:", err)
+			finishProducer(filename, p)
+			return
+		}
+
+		// Giảm delay để stream mượt hơn
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// finishProducer evicts p from producers so a subscriber joining
+// after the file has already been fully read starts a fresh reader
+// instead of attaching to a broadcaster that will never publish
+// again.
+func finishProducer(filename string, p *fileProducer) {
+	producersMu.Lock()
+	p.done = true
+	evictProducer(filename, p)
+	producersMu.Unlock()
+}
+
+// finishProducerWithStatus is finishProducer plus publishing the
+// terminal status packet (e.g. the end-of-file marker) via the
+// KeepLast status broadcaster, so subscribers already attached when
+// the file ends are notified. Eviction happens under producersMu
+// before the publish, and subscribeToFile registers its subscriptions
+// under the same lock, so a subscriber that found p is registered in
+// time to receive the marker, while one joining after this point can
+// never find p in producers and always starts a fresh reader instead.
+func finishProducerWithStatus(filename string, p *fileProducer, final stream.Packet) {
+	producersMu.Lock()
+	p.done = true
+	evictProducer(filename, p)
+	producersMu.Unlock()
+
+	p.status.Publish(final)
+}
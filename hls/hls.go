@@ -0,0 +1,128 @@
+// Package hls segments encoded audio into HLS media segments and
+// renders the accompanying .m3u8 playlist, reusing whatever
+// read/encode pipeline the caller already has in place.
+package hls
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Mode selects how the playlist is rendered.
+type Mode int
+
+const (
+	// VOD renders a complete playlist with #EXT-X-ENDLIST for a
+	// finite source.
+	VOD Mode = iota
+	// Live renders a sliding window over the most recent segments,
+	// incrementing #EXT-X-MEDIA-SEQUENCE as older ones are dropped.
+	Live
+)
+
+// Segment is one chunk of encoded audio in the HLS timeline.
+type Segment struct {
+	Index    int
+	Data     []byte
+	Duration float64
+}
+
+// Segmenter accumulates Segments for one stream and renders the
+// corresponding playlist. In Live mode it keeps only the most recent
+// maxSegments, matching a typical ~24s sliding window at ~4s/segment.
+type Segmenter struct {
+	mu          sync.Mutex
+	mode        Mode
+	maxSegments int
+	extension   string
+
+	segments  []Segment
+	mediaSeq  int
+	nextIndex int
+	ended     bool
+}
+
+// New creates a Segmenter. extension is the file extension used when
+// building segment URIs (e.g. "mp3"). maxSegments only applies in
+// Live mode.
+func New(mode Mode, extension string, maxSegments int) *Segmenter {
+	if maxSegments <= 0 {
+		maxSegments = 6
+	}
+	return &Segmenter{mode: mode, extension: extension, maxSegments: maxSegments}
+}
+
+// Append adds a newly encoded segment of the given duration (seconds)
+// to the timeline, dropping the oldest one first in Live mode once
+// maxSegments is exceeded.
+func (s *Segmenter) Append(data []byte, duration float64) Segment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seg := Segment{Index: s.nextIndex, Data: data, Duration: duration}
+	s.nextIndex++
+	s.segments = append(s.segments, seg)
+
+	if s.mode == Live && len(s.segments) > s.maxSegments {
+		s.segments = s.segments[1:]
+		s.mediaSeq++
+	}
+
+	return seg
+}
+
+// End marks the stream as finished, so Playlist emits
+// #EXT-X-ENDLIST. Only meaningful in VOD mode.
+func (s *Segmenter) End() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ended = true
+}
+
+// Segment returns the segment with the given index, if it's still
+// within the retained window.
+func (s *Segmenter) Segment(index int) (Segment, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, seg := range s.segments {
+		if seg.Index == index {
+			return seg, true
+		}
+	}
+	return Segment{}, false
+}
+
+// Playlist renders the current .m3u8 for this stream.
+func (s *Segmenter) Playlist() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var targetDuration float64
+	for _, seg := range s.segments {
+		if seg.Duration > targetDuration {
+			targetDuration = seg.Duration
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:3\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", int(targetDuration)+1)
+	fmt.Fprintf(&b, "#EXT-X-MEDIA-SEQUENCE:%d\n", s.mediaSeq)
+	if s.mode == VOD {
+		b.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n")
+	}
+
+	for _, seg := range s.segments {
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n", seg.Duration)
+		fmt.Fprintf(&b, "seg-%d.%s\n", seg.Index, s.extension)
+	}
+
+	if s.mode == VOD && s.ended {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/dangquyitt/go-audio-streaming/encoder"
+	"github.com/dangquyitt/go-audio-streaming/hls"
+)
+
+// hlsSegmentSeconds is the target duration of each HLS media segment.
+const hlsSegmentSeconds = 4.0
+
+// hlsExtension is the container used for HLS media segments; mp3 is
+// used rather than aac since that's the codec the encoder package
+// supports today.
+const hlsExtension = "mp3"
+
+// hlsLiveMount is the special mount name that serves the queue's
+// live playback instead of segmenting a single VOD file.
+const hlsLiveMount = "live"
+
+type hlsEntry struct {
+	once      sync.Once
+	segmenter *hls.Segmenter
+}
+
+var (
+	hlsMu      sync.Mutex
+	hlsEntries = make(map[string]*hlsEntry)
+)
+
+// handleHLS serves both the rolling playlist.m3u8 and the individual
+// segment files under /hls/{file}/.
+func handleHLS(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/hls/")
+	parts := strings.SplitN(rest, "/", 2)
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	mount, resource := parts[0], parts[1]
+
+	segmenter, err := getHLSSegmenter(mount)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if resource == "playlist.m3u8" {
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		w.Write([]byte(segmenter.Playlist()))
+		return
+	}
+
+	var index int
+	if _, err := fmt.Sscanf(resource, "seg-%d."+hlsExtension, &index); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	seg, ok := segmenter.Segment(index)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "audio/mpeg")
+	w.Write(seg.Data)
+}
+
+// getHLSSegmenter returns the Segmenter for mount: the shared Live
+// segmenter fed by the queue for hlsLiveMount, or a per-file VOD
+// segmenter, segmented in full on first access and reused afterwards.
+// Only .wav sources can be segmented today since that's the only
+// format the server decodes to PCM, so a non-wav mount is rejected
+// instead of silently serving an empty playlist.
+func getHLSSegmenter(mount string) (*hls.Segmenter, error) {
+	if mount == hlsLiveMount {
+		return liveSegmenter, nil
+	}
+
+	if filepath.Ext(mount) != ".wav" {
+		return nil, fmt.Errorf("hls: %s is not a WAV source, only WAV can be segmented for HLS", mount)
+	}
+
+	hlsMu.Lock()
+	entry, ok := hlsEntries[mount]
+	if !ok {
+		entry = &hlsEntry{segmenter: hls.New(hls.VOD, hlsExtension, 0)}
+		hlsEntries[mount] = entry
+	}
+	hlsMu.Unlock()
+
+	entry.once.Do(func() {
+		segmentWavFile(mount, entry.segmenter)
+	})
+	return entry.segmenter, nil
+}
+
+// segmentWavFile decodes filename and re-encodes it to MP3 through
+// the same encoder pipeline streamTranscoded uses, cutting a new HLS
+// segment roughly every hlsSegmentSeconds of audio.
+func segmentWavFile(filename string, segmenter *hls.Segmenter) {
+	filePath := filepath.Join("resource", filename)
+	file, err := os.Open(filePath)
+	if err != nil {
+		log.Println("Error opening file for HLS segmentation:", err)
+		return
+	}
+	defer file.Close()
+
+	info, err := readWavHeader(file)
+	if err != nil {
+		log.Println("Error reading WAV header for HLS segmentation:", err)
+		return
+	}
+
+	enc, err := encoder.New(encoder.FormatMP3, encoder.Options{
+		SampleRate:  info.SampleRate,
+		Channels:    info.Channels,
+		BitrateKbps: defaultTranscodeBitrate,
+	})
+	if err != nil {
+		log.Println("Error creating encoder for HLS segmentation:", err)
+		return
+	}
+
+	pcm := make([]int16, transcodeSamplesPerRead*info.Channels)
+	var segmentBuf bytes.Buffer
+	var segmentSeconds float64
+
+	flush := func() {
+		if segmentBuf.Len() == 0 {
+			return
+		}
+		data := make([]byte, segmentBuf.Len())
+		copy(data, segmentBuf.Bytes())
+		segmenter.Append(data, segmentSeconds)
+		segmentBuf.Reset()
+		segmentSeconds = 0
+	}
+
+	for {
+		n, readErr := readPCMSamples(file, pcm)
+		if n > 0 {
+			encoded, encErr := enc.Encode(pcm[:n])
+			if encErr == nil {
+				segmentBuf.Write(encoded)
+				audioChunksSent.Inc()
+			}
+
+			segmentSeconds += float64(n/info.Channels) / float64(info.SampleRate)
+			if segmentSeconds >= hlsSegmentSeconds {
+				flush()
+			}
+		}
+
+		if readErr != nil {
+			if final, closeErr := enc.Close(); closeErr == nil && len(final) > 0 {
+				segmentBuf.Write(final)
+			}
+			flush()
+			segmenter.End()
+			return
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dangquyitt/go-audio-streaming/encoder"
+	"github.com/dangquyitt/go-audio-streaming/hls"
+	"github.com/dangquyitt/go-audio-streaming/queue"
+)
+
+// liveSegmenter is the single HLS Live segmenter served at
+// /hls/live/, mirroring whatever the queue feeder is currently
+// playing over WebSocket.
+var liveSegmenter = hls.New(hls.Live, hlsExtension, 6)
+
+// segmentQueueTrackForLive decodes track a second time (independent
+// of the raw byte relay playQueueTrack sends to WebSocket clients)
+// and re-encodes it to MP3 segments on liveSegmenter. Only WAV tracks
+// can be segmented today, matching the VOD path's limitation.
+func segmentQueueTrackForLive(track *queue.QueueTrackEntry) {
+	if filepath.Ext(track.Path) != ".wav" {
+		log.Println("Skipping HLS live segmentation for non-WAV track:", track.Path)
+		return
+	}
+
+	filePath := filepath.Join("resource", track.Path)
+	file, err := os.Open(filePath)
+	if err != nil {
+		log.Println("Error opening queue track for HLS live:", err)
+		return
+	}
+	defer file.Close()
+
+	info, err := readWavHeader(file)
+	if err != nil {
+		log.Println("Error reading WAV header for HLS live:", err)
+		return
+	}
+
+	enc, err := encoder.New(encoder.FormatMP3, encoder.Options{
+		SampleRate:  info.SampleRate,
+		Channels:    info.Channels,
+		BitrateKbps: defaultTranscodeBitrate,
+	})
+	if err != nil {
+		log.Println("Error creating encoder for HLS live:", err)
+		return
+	}
+
+	pcm := make([]int16, transcodeSamplesPerRead*info.Channels)
+	var segmentBuf bytes.Buffer
+	var segmentSeconds float64
+
+	// startTime/playedAudio pace segmentation to the audio's own
+	// duration, so the Live window advances at playback speed rather
+	// than racing ahead and filling the 6-segment window within moments
+	// of the track starting. playQueueTrack's raw byte relay is paced
+	// separately (a fixed sleep per read), so the two are not kept in
+	// step.
+	startTime := time.Now()
+	var playedAudio time.Duration
+
+	flush := func() {
+		if segmentBuf.Len() == 0 {
+			return
+		}
+		data := make([]byte, segmentBuf.Len())
+		copy(data, segmentBuf.Bytes())
+		liveSegmenter.Append(data, segmentSeconds)
+		segmentBuf.Reset()
+		segmentSeconds = 0
+	}
+
+	for {
+		select {
+		case <-queueFeederStop:
+			return
+		default:
+		}
+
+		n, readErr := readPCMSamples(file, pcm)
+		if n > 0 {
+			encoded, encErr := enc.Encode(pcm[:n])
+			if encErr == nil {
+				segmentBuf.Write(encoded)
+			}
+
+			chunkSeconds := float64(n/info.Channels) / float64(info.SampleRate)
+			segmentSeconds += chunkSeconds
+			if segmentSeconds >= hlsSegmentSeconds {
+				flush()
+			}
+
+			playedAudio += time.Duration(chunkSeconds * float64(time.Second))
+			if wait := playedAudio - time.Since(startTime); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-queueFeederStop:
+					return
+				}
+			}
+		}
+
+		if readErr != nil {
+			if final, closeErr := enc.Close(); closeErr == nil && len(final) > 0 {
+				segmentBuf.Write(final)
+			}
+			flush()
+			return
+		}
+	}
+}
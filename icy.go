@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// icyMetaInterval is the number of audio bytes sent between each ICY
+// metadata block, matching the Shoutcast/Icecast convention (most
+// encoders use 8000-16000).
+const icyMetaInterval = 16000
+
+// icyDefaultBitrateKbps is used when the source file's bitrate can't
+// be detected from its MP3 frame headers.
+const icyDefaultBitrateKbps = 128
+
+// icyUnchangedMetaBlock is the single zero byte the ICY protocol uses
+// to mean "metadata unchanged since the last block", saving clients
+// from re-parsing an identical StreamTitle on every interval.
+var icyUnchangedMetaBlock = []byte{0}
+
+// handleIcecastStream serves ./resource/<mount> as a continuous
+// Icecast/SHOUTcast-compatible stream so standard players (mpv, vlc,
+// browsers) can tune in directly, alongside the existing /ws handler.
+// Only .mp3 sources are supported since the ICY metadata blocks are
+// spliced into MP3 frame payloads; a non-mp3 mount is rejected
+// instead of mislabeling raw PCM as audio/mpeg.
+func handleIcecastStream(w http.ResponseWriter, r *http.Request) {
+	mount := strings.TrimPrefix(r.URL.Path, "/stream/")
+	if mount == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	if filepath.Ext(mount) != ".mp3" {
+		http.Error(w, fmt.Sprintf("icy: %s is not an MP3 source, only MP3 can be streamed as Icecast", mount), http.StatusBadRequest)
+		return
+	}
+
+	filePath := filepath.Join("resource", mount)
+	file, err := os.Open(filePath)
+	if err != nil {
+		http.Error(w, "Failed to open audio file", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	clientIP := r.RemoteAddr
+	log.Printf("New Icecast stream connection from %s for %s", clientIP, mount)
+
+	icyMeta := r.Header.Get("Icy-MetaData") == "1"
+
+	sniff := make([]byte, 4096)
+	sniffN, _ := io.ReadFull(file, sniff)
+	bitrateKbps, err := mp3Bitrate(sniff[:sniffN])
+	if err != nil {
+		log.Println("Could not detect MP3 bitrate, assuming default:", err)
+		bitrateKbps = icyDefaultBitrateKbps
+	}
+	body := io.MultiReader(bytes.NewReader(sniff[:sniffN]), file)
+
+	w.Header().Set("Content-Type", "audio/mpeg")
+	w.Header().Set("icy-name", "go-audio-streaming")
+	w.Header().Set("icy-genre", "Various")
+	w.Header().Set("icy-br", strconv.Itoa(bitrateKbps))
+	w.Header().Set("Cache-Control", "no-cache")
+	if icyMeta {
+		w.Header().Set("icy-metaint", strconv.Itoa(icyMetaInterval))
+	}
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+
+	title := fmt.Sprintf("Unknown Artist - %s", strings.TrimSuffix(mount, filepath.Ext(mount)))
+	metaBlock := buildICYMetadataBlock(title)
+	metaSent := false
+
+	buffer := make([]byte, 4096)
+	sinceMeta := 0
+	bytesPerSecond := float64(bitrateKbps) * 1000 / 8
+
+	for {
+		n, err := body.Read(buffer)
+		if n > 0 {
+			chunk := buffer[:n]
+			for len(chunk) > 0 {
+				remaining := icyMetaInterval - sinceMeta
+				take := len(chunk)
+				if icyMeta && take > remaining {
+					take = remaining
+				}
+
+				if _, werr := w.Write(chunk[:take]); werr != nil {
+					log.Println("Error writing Icecast stream:", werr)
+					websocketErrors.Inc()
+					return
+				}
+				audioChunksSent.Inc()
+				sinceMeta += take
+				chunk = chunk[take:]
+
+				if icyMeta && sinceMeta >= icyMetaInterval {
+					// The title is static for the life of this
+					// connection, so only the very first block carries
+					// it; every later interval reports "unchanged".
+					block := icyUnchangedMetaBlock
+					if !metaSent {
+						block = metaBlock
+						metaSent = true
+					}
+					if _, werr := w.Write(block); werr != nil {
+						log.Println("Error writing ICY metadata block:", werr)
+						return
+					}
+					sinceMeta = 0
+				}
+			}
+
+			if flusher != nil {
+				flusher.Flush()
+			}
+
+			// Pace the stream by the assumed bitrate so late-joining
+			// clients hear real-time playback instead of a burst.
+			time.Sleep(time.Duration(float64(n) / bytesPerSecond * float64(time.Second)))
+		}
+
+		if err == io.EOF {
+			log.Printf("Finished Icecast stream for %s", mount)
+			return
+		}
+		if err != nil {
+			log.Println("Error reading file for Icecast stream:", err)
+			return
+		}
+	}
+}
+
+// buildICYMetadataBlock encodes title as an ICY "StreamTitle" metadata
+// block: a single length byte (block length / 16) followed by the
+// StreamTitle='...'; string, zero-padded to a multiple of 16 bytes.
+func buildICYMetadataBlock(title string) []byte {
+	payload := fmt.Sprintf("StreamTitle='%s';", title)
+
+	padded := len(payload)
+	if padded%16 != 0 {
+		padded += 16 - padded%16
+	}
+	if padded > 16*255 {
+		padded = 16 * 255
+	}
+
+	block := make([]byte, 1+padded)
+	block[0] = byte(padded / 16)
+	copy(block[1:], payload)
+	return block
+}
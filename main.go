@@ -1,22 +1,32 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
 	"sync"
+	"syscall"
 	"time"
 
+	"github.com/dangquyitt/go-audio-streaming/replaygain"
 	"github.com/gorilla/websocket"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// shutdownTimeout bounds how long graceful shutdown waits for
+// in-flight streamAudio goroutines to finish before the process exits
+// anyway.
+const shutdownTimeout = 10 * time.Second
+
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
@@ -66,10 +76,41 @@ type Client struct {
 	audioFile  string
 }
 
+// clients tracks every connected WebSocket client so now-playing
+// updates from the queue subsystem can be broadcast to all of them.
+var (
+	clients   = make(map[*Client]struct{})
+	clientsMu sync.Mutex
+)
+
+// streamWG tracks in-flight streamAudio goroutines so graceful
+// shutdown can wait for them to wind down before the process exits.
+var streamWG sync.WaitGroup
+
 // Message represents a WebSocket message
 type Message struct {
-	Action   string `json:"action"`
-	Filename string `json:"filename,omitempty"`
+	Action     string  `json:"action"`
+	Filename   string  `json:"filename,omitempty"`
+	Format     string  `json:"format,omitempty"`
+	Bitrate    int     `json:"bitrate,omitempty"`
+	TargetLUFS float64 `json:"targetLUFS,omitempty"`
+}
+
+// FormatMessage announces the codec, sample rate and channel count
+// negotiated for a transcoded stream so the client can configure its
+// decoder before the first audio frame arrives.
+type FormatMessage struct {
+	Type       string `json:"type"`
+	Codec      string `json:"codec"`
+	SampleRate int    `json:"sampleRate"`
+	Channels   int    `json:"channels"`
+}
+
+// AudioFileInfo describes one entry in the /audios listing, including
+// its ReplayGain analysis when one is available.
+type AudioFileInfo struct {
+	Name       string             `json:"name"`
+	ReplayGain *replaygain.Result `json:"replayGain,omitempty"`
 }
 
 // StatusMessage represents a status message to send to the client
@@ -101,11 +142,20 @@ func main() {
 			return
 		}
 
-		var audioFiles []string
+		var audioFiles []AudioFileInfo
 		for _, file := range files {
-			if !file.IsDir() && (filepath.Ext(file.Name()) == ".mp3" || filepath.Ext(file.Name()) == ".wav") {
-				audioFiles = append(audioFiles, file.Name())
+			ext := filepath.Ext(file.Name())
+			if file.IsDir() || (ext != ".mp3" && ext != ".wav") {
+				continue
 			}
+
+			info := AudioFileInfo{Name: file.Name()}
+			if ext == ".wav" {
+				if rg, err := loadOrComputeReplayGain(filepath.Join("resource", file.Name())); err == nil {
+					info.ReplayGain = &rg
+				}
+			}
+			audioFiles = append(audioFiles, info)
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -115,15 +165,92 @@ func main() {
 	// WebSocket endpoint
 	mux.HandleFunc("/ws", handleWebSocket)
 
-	server := http.Server{
+	// Icecast/SHOUTcast-compatible HTTP streaming endpoint
+	mux.HandleFunc("/stream/", handleIcecastStream)
+
+	// Playlist/queue management endpoints
+	mux.HandleFunc("/api/queue", handleQueueCollection)
+	mux.HandleFunc("/api/queue/move", handleQueueMove)
+	mux.HandleFunc("/api/queue/", handleQueueTrack)
+
+	// HLS output alongside the WebSocket stream
+	mux.HandleFunc("/hls/", handleHLS)
+
+	go broadcastNowPlaying()
+	go prescanReplayGain("./resource")
+	go startQueueFeeder()
+
+	server := &http.Server{
 		Addr:    ":8080",
 		Handler: mux,
 	}
 
-	log.Println("Starting server on port 8080")
+	listener, err := systemdListener()
+	if err != nil {
+		log.Fatal("Failed to use systemd socket activation:", err)
+	}
+	if listener == nil {
+		listener, err = net.Listen("tcp", server.Addr)
+		if err != nil {
+			log.Fatal("Failed to listen:", err)
+		}
+		log.Printf("Starting server on %s", server.Addr)
+	} else {
+		log.Println("Starting server on systemd-activated socket")
+	}
+
 	log.Println("Metrics available at http://localhost:8080/metrics")
 	log.Println("Open http://localhost:8080 in your browser")
-	log.Fatal(server.ListenAndServe())
+
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Fatal("Server error:", err)
+		}
+	}()
+
+	waitForShutdown(server)
+}
+
+// waitForShutdown blocks until SIGINT/SIGTERM is received, then stops
+// all active clients, waits (with a timeout) for their streamAudio
+// goroutines to finish, and shuts the HTTP server down cleanly so
+// metrics counters aren't dropped mid-write.
+func waitForShutdown(server *http.Server) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-sigCh
+	log.Printf("Received %s, shutting down gracefully", sig)
+
+	close(queueFeederStop)
+
+	clientsMu.Lock()
+	for client := range clients {
+		client.mu.Lock()
+		if client.streaming {
+			close(client.stopCh)
+			client.streaming = false
+		}
+		client.mu.Unlock()
+	}
+	clientsMu.Unlock()
+
+	streamDone := make(chan struct{})
+	go func() {
+		streamWG.Wait()
+		close(streamDone)
+	}()
+
+	select {
+	case <-streamDone:
+	case <-time.After(shutdownTimeout):
+		log.Println("Timed out waiting for streams to finish, shutting down anyway")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Println("Error during server shutdown:", err)
+	}
 }
 
 func handleWebSocket(w http.ResponseWriter, r *http.Request) {
@@ -149,6 +276,15 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		clientIP:  clientIP,
 	}
 
+	clientsMu.Lock()
+	clients[client] = struct{}{}
+	clientsMu.Unlock()
+	defer func() {
+		clientsMu.Lock()
+		delete(clients, client)
+		clientsMu.Unlock()
+	}()
+
 	// Listen for messages from the client
 	for {
 		messageType, p, err := conn.ReadMessage()
@@ -194,7 +330,38 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 				// Increment streaming counter
 				streamingCounter.Inc()
 
-				go streamAudio(client, msg.Filename)
+				streamWG.Add(1)
+				go func() {
+					defer streamWG.Done()
+					streamAudio(client, msg.Filename, msg.Format, msg.Bitrate, msg.TargetLUFS)
+				}()
+
+			case "queue":
+				// Stop any existing streaming
+				if client.streaming {
+					client.mu.Lock()
+					close(client.stopCh)
+					client.stopCh = make(chan struct{})
+					client.mu.Unlock()
+
+					// Record duration of previous stream
+					duration := time.Since(client.startTime).Seconds()
+					audioStreamDuration.Observe(duration)
+				}
+
+				client.streaming = true
+				client.startTime = time.Now()
+				client.totalBytes = 0
+				client.audioFile = ""
+
+				// Increment streaming counter
+				streamingCounter.Inc()
+
+				streamWG.Add(1)
+				go func() {
+					defer streamWG.Done()
+					streamQueueToClient(client)
+				}()
 
 			case "stop":
 				if client.streaming {
@@ -215,7 +382,7 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func streamAudio(client *Client, filename string) {
+func streamAudio(client *Client, filename string, format string, bitrateKbps int, targetLUFS float64) {
 	filePath := filepath.Join("resource", filename)
 
 	// Check if file exists
@@ -227,52 +394,67 @@ func streamAudio(client *Client, filename string) {
 
 	sendStatusMessage(client, fmt.Sprintf("Streaming %s", filename))
 
-	file, err := os.Open(filePath)
-	if err != nil {
-		log.Println("Error opening file:", err)
-		sendStatusMessage(client, "Error opening audio file")
-		websocketErrors.Inc()
+	// WAV/PCM sources are transcoded to the requested output codec
+	// instead of being relayed as-is.
+	if filepath.Ext(filename) == ".wav" {
+		file, err := os.Open(filePath)
+		if err != nil {
+			log.Println("Error opening file:", err)
+			sendStatusMessage(client, "Error opening audio file")
+			websocketErrors.Inc()
+			return
+		}
+		defer file.Close()
+
+		streamTranscoded(client, file, filename, format, bitrateKbps, targetLUFS)
 		return
 	}
-	defer file.Close()
 
-	// Get file info for logging
-	fileInfo, err := file.Stat()
-	if err == nil {
-		log.Printf("Streaming file: %s (size: %d bytes)", filename, fileInfo.Size())
+	// Already-compressed sources are relayed as-is; there's no decoder
+	// to re-encode them, so a request for a different output format
+	// can't be honored and is rejected rather than silently ignored. A
+	// requested bitrate is not applied to these sources.
+	sourceFormat := strings.TrimPrefix(filepath.Ext(filename), ".")
+	if format != "" && format != sourceFormat {
+		sendStatusMessage(client, fmt.Sprintf("Error: cannot transcode %s source to %s, no decoder available", sourceFormat, format))
+		websocketErrors.Inc()
+		return
 	}
 
-	// Buffer for reading chunks of the audio file
-	buffer := make([]byte, 8192) // 8KB chunks - tối ưu cho streaming audio
+	// Already-compressed sources are relayed through the shared
+	// packetizer so N listeners of the same file only cost one
+	// reader goroutine instead of N.
+	packets, unsubscribe := subscribeToFile(filename)
+	defer unsubscribe()
 
 	for {
 		select {
 		case <-client.stopCh:
 			log.Printf("Streaming stopped for file: %s", filename)
 			return
-		default:
-			n, err := file.Read(buffer)
-			if err == io.EOF {
+
+		case packet, ok := <-packets:
+			if !ok {
+				log.Printf("Disconnected slow client for file: %s", filename)
+				sendStatusMessage(client, "Disconnected: client fell behind")
+				websocketErrors.Inc()
+				client.streaming = false
+				return
+			}
+
+			if len(packet.Data) == 0 {
 				log.Printf("Finished streaming file: %s", filename)
 				sendStatusMessage(client, "Streaming finished")
 
-				// Record duration when finished
 				duration := time.Since(client.startTime).Seconds()
 				audioStreamDuration.Observe(duration)
 
 				client.streaming = false
 				return
 			}
-			if err != nil {
-				log.Println("Error reading file:", err)
-				sendStatusMessage(client, "Error reading audio file")
-				websocketErrors.Inc()
-				client.streaming = false
-				return
-			}
 
 			client.mu.Lock()
-			err = client.conn.WriteMessage(websocket.BinaryMessage, buffer[:n])
+			err := client.conn.WriteMessage(websocket.BinaryMessage, packet.Data)
 			client.mu.Unlock()
 
 			if err != nil {
@@ -282,12 +464,8 @@ func streamAudio(client *Client, filename string) {
 				return
 			}
 
-			// Increment metrics
 			audioChunksSent.Inc()
-			client.totalBytes += n
-
-			// Giảm delay để stream mượt hơn
-			time.Sleep(20 * time.Millisecond)
+			client.totalBytes += len(packet.Data)
 		}
 	}
 }
@@ -0,0 +1,38 @@
+package main
+
+import "fmt"
+
+// mp3BitrateTableV1L3 is the MPEG-1 Layer III bitrate table in
+// kbit/s, indexed by the 4-bit bitrate field of the frame header.
+// Index 0 means "free format" and 15 is reserved; both are treated
+// as unknown by mp3Bitrate.
+var mp3BitrateTableV1L3 = [16]int{
+	0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0,
+}
+
+// mp3Bitrate scans the first MPEG-1 Layer III frame header found in
+// data and returns its bitrate in kbit/s. It returns an error if no
+// valid frame sync is found, which is enough to fall back to a
+// default bitrate for anything that isn't a plain MP3 file (e.g. a
+// stray ID3 tag at the start of the file).
+func mp3Bitrate(data []byte) (int, error) {
+	for i := 0; i+4 <= len(data); i++ {
+		if data[i] != 0xFF || data[i+1]&0xE0 != 0xE0 {
+			continue
+		}
+
+		version := (data[i+1] >> 3) & 0x03
+		layer := (data[i+1] >> 1) & 0x03
+		if version != 0x03 || layer != 0x01 { // MPEG-1, Layer III
+			continue
+		}
+
+		bitrateIndex := (data[i+2] >> 4) & 0x0F
+		kbps := mp3BitrateTableV1L3[bitrateIndex]
+		if kbps == 0 {
+			continue
+		}
+		return kbps, nil
+	}
+	return 0, fmt.Errorf("mp3: no MPEG-1 Layer III frame sync found")
+}
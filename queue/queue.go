@@ -0,0 +1,147 @@
+// Package queue implements a multi-track playlist that feeds a shared
+// audio source, replacing the one-file-per-connection model used by
+// the WebSocket handler.
+package queue
+
+import (
+	"fmt"
+	"sync"
+)
+
+// QueueTrackEntry describes a single track in the queue.
+type QueueTrackEntry struct {
+	ID     string `json:"id"`
+	Path   string `json:"path"`
+	Title  string `json:"title"`
+	Artist string `json:"artist"`
+	Album  string `json:"album"`
+	Art    string `json:"art,omitempty"`
+}
+
+// Queue holds an ordered list of tracks and broadcasts the currently
+// playing one to anyone subscribed via NowPlaying.
+type Queue struct {
+	mu      sync.Mutex
+	tracks  []*QueueTrackEntry
+	nextID  int
+	current *QueueTrackEntry
+	arrived chan struct{}
+
+	// NowPlaying emits the track whenever playback advances to it.
+	// Subscribers must keep up or messages will be dropped.
+	NowPlaying chan *QueueTrackEntry
+}
+
+// New creates an empty Queue.
+func New() *Queue {
+	return &Queue{
+		arrived:    make(chan struct{}),
+		NowPlaying: make(chan *QueueTrackEntry, 1),
+	}
+}
+
+// Append adds a track to the end of the queue and returns it with its
+// assigned ID, waking any goroutine blocked in Next.
+func (q *Queue) Append(entry QueueTrackEntry) *QueueTrackEntry {
+	q.mu.Lock()
+	q.nextID++
+	entry.ID = fmt.Sprintf("%d", q.nextID)
+	track := &entry
+	q.tracks = append(q.tracks, track)
+
+	arrived := q.arrived
+	q.arrived = make(chan struct{})
+	q.mu.Unlock()
+
+	close(arrived)
+	return track
+}
+
+// Remove deletes the track with the given ID from the queue. It
+// reports whether a track was found and removed.
+func (q *Queue) Remove(id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, t := range q.tracks {
+		if t.ID == id {
+			q.tracks = append(q.tracks[:i], q.tracks[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Move repositions the track with the given ID to toIndex in the
+// queue. It reports whether the move was applied.
+func (q *Queue) Move(id string, toIndex int) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	fromIndex := -1
+	for i, t := range q.tracks {
+		if t.ID == id {
+			fromIndex = i
+			break
+		}
+	}
+	if fromIndex == -1 || toIndex < 0 || toIndex >= len(q.tracks) {
+		return false
+	}
+
+	track := q.tracks[fromIndex]
+	rest := append(q.tracks[:fromIndex], q.tracks[fromIndex+1:]...)
+
+	insertAt := toIndex
+	q.tracks = append(rest[:insertAt], append([]*QueueTrackEntry{track}, rest[insertAt:]...)...)
+	return true
+}
+
+// List returns a snapshot of the current queue order.
+func (q *Queue) List() []*QueueTrackEntry {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	out := make([]*QueueTrackEntry, len(q.tracks))
+	copy(out, q.tracks)
+	return out
+}
+
+// Next pops the first track off the queue, publishes it on NowPlaying
+// and returns it. If the queue is empty it blocks until a track is
+// appended or stop is closed, in which case it returns nil.
+func (q *Queue) Next(stop <-chan struct{}) *QueueTrackEntry {
+	for {
+		q.mu.Lock()
+		if len(q.tracks) > 0 {
+			track := q.tracks[0]
+			q.tracks = q.tracks[1:]
+			q.current = track
+			q.mu.Unlock()
+
+			select {
+			case q.NowPlaying <- track:
+			default:
+				// Drop if no one is listening right now; the next
+				// subscriber will get the following track.
+			}
+			return track
+		}
+
+		arrived := q.arrived
+		q.mu.Unlock()
+
+		select {
+		case <-arrived:
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+// Current returns the track that is currently playing, if any.
+func (q *Queue) Current() *QueueTrackEntry {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.current
+}
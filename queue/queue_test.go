@@ -0,0 +1,28 @@
+package queue
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQueueMove(t *testing.T) {
+	q := New()
+	ids := make([]string, 5)
+	for i, name := range []string{"A", "B", "C", "D", "E"} {
+		track := q.Append(QueueTrackEntry{Title: name})
+		ids[i] = track.ID
+	}
+
+	if !q.Move(ids[1], 4) {
+		t.Fatal("Move reported failure")
+	}
+
+	got := make([]string, 0, 5)
+	for _, track := range q.List() {
+		got = append(got, track.Title)
+	}
+	want := []string{"A", "C", "D", "E", "B"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Move(B, 4) = %v, want %v", got, want)
+	}
+}
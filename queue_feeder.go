@@ -0,0 +1,144 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dangquyitt/go-audio-streaming/queue"
+	"github.com/dangquyitt/go-audio-streaming/stream"
+	"github.com/gorilla/websocket"
+)
+
+// queuePrebufferPackets is how many of the most recently published
+// packets a newly subscribed client is fast-started with.
+const queuePrebufferPackets = 5
+
+var (
+	queueBroadcaster = stream.NewBroadcaster()
+	queueFeederStop  = make(chan struct{})
+
+	recentQueuePacketsMu sync.Mutex
+	recentQueuePackets   []stream.Packet
+)
+
+// startQueueFeeder continuously pulls tracks off trackQueue and
+// publishes their audio to every subscribed WebSocket client,
+// replacing the one-file-per-connection model for queue playback. It
+// runs until queueFeederStop is closed.
+func startQueueFeeder() {
+	for {
+		track := trackQueue.Next(queueFeederStop)
+		if track == nil {
+			return
+		}
+		playQueueTrack(track)
+	}
+}
+
+// playQueueTrack streams one track's file into queueBroadcaster until
+// it ends, queueFeederStop is closed, or the file can't be read.
+func playQueueTrack(track *queue.QueueTrackEntry) {
+	filePath := filepath.Join("resource", track.Path)
+	file, err := os.Open(filePath)
+	if err != nil {
+		log.Println("Error opening queue track:", err)
+		return
+	}
+	defer file.Close()
+
+	go segmentQueueTrackForLive(track)
+
+	buffer := make([]byte, 8192)
+
+	for {
+		select {
+		case <-queueFeederStop:
+			return
+		default:
+		}
+
+		n, err := file.Read(buffer)
+		if n > 0 {
+			chunk := make([]byte, n)
+			copy(chunk, buffer[:n])
+			packet := stream.Packet{Data: chunk}
+			queueBroadcaster.Publish(packet)
+			cacheRecentQueuePacket(packet)
+		}
+
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			log.Println("Error reading queue track:", err)
+			return
+		}
+
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// cacheRecentQueuePacket keeps the last queuePrebufferPackets packets
+// around so subscribeToQueue can fast-start new subscribers.
+func cacheRecentQueuePacket(p stream.Packet) {
+	recentQueuePacketsMu.Lock()
+	defer recentQueuePacketsMu.Unlock()
+
+	recentQueuePackets = append(recentQueuePackets, p)
+	if len(recentQueuePackets) > queuePrebufferPackets {
+		recentQueuePackets = recentQueuePackets[1:]
+	}
+}
+
+// subscribeToQueue joins the live queue broadcast, seeding the new
+// subscriber with a small pre-buffer before switching to real-time
+// pacing.
+func subscribeToQueue() (<-chan stream.Packet, func()) {
+	recentQueuePacketsMu.Lock()
+	prebuffer := append([]stream.Packet(nil), recentQueuePackets...)
+	recentQueuePacketsMu.Unlock()
+
+	return queueBroadcaster.SubscribeWithPrebuffer(stream.KeepAll, stream.DefaultRingCapacity, prebuffer)
+}
+
+// streamQueueToClient relays the shared queue broadcast to client
+// until it stops streaming or falls behind.
+func streamQueueToClient(client *Client) {
+	packets, unsubscribe := subscribeToQueue()
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-client.stopCh:
+			log.Println("Queue streaming stopped for client", client.clientIP)
+			return
+
+		case packet, ok := <-packets:
+			if !ok {
+				log.Println("Disconnected slow queue client", client.clientIP)
+				sendStatusMessage(client, "Disconnected: client fell behind")
+				websocketErrors.Inc()
+				client.streaming = false
+				return
+			}
+
+			client.mu.Lock()
+			err := client.conn.WriteMessage(websocket.BinaryMessage, packet.Data)
+			client.mu.Unlock()
+
+			if err != nil {
+				log.Println("Error writing to WebSocket:", err)
+				websocketErrors.Inc()
+				client.streaming = false
+				return
+			}
+
+			audioChunksSent.Inc()
+			client.totalBytes += len(packet.Data)
+		}
+	}
+}
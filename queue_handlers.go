@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/dangquyitt/go-audio-streaming/queue"
+	"github.com/gorilla/websocket"
+)
+
+// trackQueue is the shared playlist consumed by the WebSocket
+// handler's now-playing broadcast.
+var trackQueue = queue.New()
+
+// NowPlayingMessage is pushed to WebSocket clients whenever the queue
+// advances to a new track.
+type NowPlayingMessage struct {
+	Type  string                 `json:"type"`
+	Track *queue.QueueTrackEntry `json:"track"`
+}
+
+// addQueueTrackRequest is the body accepted by POST /api/queue.
+type addQueueTrackRequest struct {
+	Path   string `json:"path"`
+	Title  string `json:"title"`
+	Artist string `json:"artist"`
+	Album  string `json:"album"`
+	Art    string `json:"art"`
+}
+
+// moveQueueTrackRequest is the body accepted by POST /api/queue/move.
+type moveQueueTrackRequest struct {
+	ID    string `json:"id"`
+	Index int    `json:"index"`
+}
+
+// queueStateResponse is the body returned by GET /api/queue.
+type queueStateResponse struct {
+	Current *queue.QueueTrackEntry   `json:"current"`
+	Tracks  []*queue.QueueTrackEntry `json:"tracks"`
+}
+
+// handleQueueCollection serves GET/POST /api/queue.
+func handleQueueCollection(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(queueStateResponse{
+			Current: trackQueue.Current(),
+			Tracks:  trackQueue.List(),
+		})
+
+	case http.MethodPost:
+		var req addQueueTrackRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.Path == "" {
+			http.Error(w, "path is required", http.StatusBadRequest)
+			return
+		}
+		if !isSafeResourcePath(req.Path) {
+			http.Error(w, "path must be a relative path inside the resource directory", http.StatusBadRequest)
+			return
+		}
+
+		track := trackQueue.Append(queue.QueueTrackEntry{
+			Path:   req.Path,
+			Title:  req.Title,
+			Artist: req.Artist,
+			Album:  req.Album,
+			Art:    req.Art,
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(track)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleQueueTrack serves DELETE /api/queue/{id}.
+func handleQueueTrack(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Path[len("/api/queue/"):]
+	if id == "" {
+		http.Error(w, "id is required", http.StatusBadRequest)
+		return
+	}
+
+	if !trackQueue.Remove(id) {
+		http.Error(w, "Track not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleQueueMove serves POST /api/queue/move.
+func handleQueueMove(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req moveQueueTrackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if !trackQueue.Move(req.ID, req.Index) {
+		http.Error(w, "Unable to move track", http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// broadcastNowPlaying forwards NowPlaying events from the queue to
+// every connected WebSocket client.
+func broadcastNowPlaying() {
+	for track := range trackQueue.NowPlaying {
+		msg := NowPlayingMessage{Type: "nowplaying", Track: track}
+		data, err := json.Marshal(msg)
+		if err != nil {
+			log.Println("Error marshaling nowplaying message:", err)
+			continue
+		}
+
+		clientsMu.Lock()
+		for client := range clients {
+			client.mu.Lock()
+			if err := client.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				log.Println("Error broadcasting nowplaying message:", err)
+				websocketErrors.Inc()
+			}
+			client.mu.Unlock()
+		}
+		clientsMu.Unlock()
+	}
+}
+
+// isSafeResourcePath reports whether path is a relative path that
+// stays inside the resource directory once joined onto it, rejecting
+// absolute paths and "../" traversal so a queued track can't read
+// arbitrary files off the host.
+func isSafeResourcePath(path string) bool {
+	if filepath.IsAbs(path) {
+		return false
+	}
+	clean := filepath.Clean(path)
+	return clean != ".." && !strings.HasPrefix(clean, "../")
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/dangquyitt/go-audio-streaming/replaygain"
+)
+
+// defaultTargetLUFS is the loudness a client's stream is normalized
+// to when its "start" message omits targetLUFS.
+const defaultTargetLUFS = -14.0
+
+// replayGainPrescanWorkers bounds how many files are analyzed
+// concurrently during the startup pre-scan.
+const replayGainPrescanWorkers = 4
+
+var (
+	replayGainMu    sync.Mutex
+	replayGainCache = make(map[string]replaygain.Result)
+)
+
+// replayGainSidecarPath returns the cache file path for a resource
+// file, e.g. "resource/track.wav.rg.json".
+func replayGainSidecarPath(filePath string) string {
+	return filePath + ".rg.json"
+}
+
+// loadOrComputeReplayGain returns the cached ReplayGain analysis for
+// filePath, computing and caching it (in-process and to a sidecar
+// JSON file) on first access. The cache key is a cheap os.Stat
+// signature (size + mtime) rather than a content hash, so the hot
+// /audios listing and "start" paths cost one stat call per file
+// instead of reading the whole file through a digest every time.
+func loadOrComputeReplayGain(filePath string) (replaygain.Result, error) {
+	key, err := statSignature(filePath)
+	if err != nil {
+		return replaygain.Result{}, err
+	}
+
+	replayGainMu.Lock()
+	if r, ok := replayGainCache[key]; ok {
+		replayGainMu.Unlock()
+		return r, nil
+	}
+	replayGainMu.Unlock()
+
+	sidecarPath := replayGainSidecarPath(filePath)
+	if data, err := os.ReadFile(sidecarPath); err == nil {
+		var sidecar replayGainSidecar
+		if json.Unmarshal(data, &sidecar) == nil && sidecar.Signature == key {
+			replayGainMu.Lock()
+			replayGainCache[key] = sidecar.Result
+			replayGainMu.Unlock()
+			return sidecar.Result, nil
+		}
+	}
+
+	r, err := computeReplayGain(filePath)
+	if err != nil {
+		return replaygain.Result{}, err
+	}
+
+	replayGainMu.Lock()
+	replayGainCache[key] = r
+	replayGainMu.Unlock()
+
+	sidecar := replayGainSidecar{Signature: key, Result: r}
+	if data, err := json.Marshal(sidecar); err == nil {
+		if err := os.WriteFile(sidecarPath, data, 0644); err != nil {
+			log.Println("Error writing ReplayGain sidecar:", err)
+		}
+	}
+
+	return r, nil
+}
+
+// replayGainSidecar is the on-disk cache entry for one resource file.
+// Signature pins the sidecar to the file state it was computed for,
+// so a stale cache entry left behind after the file changes is
+// detected and recomputed instead of served forever.
+type replayGainSidecar struct {
+	Signature string            `json:"signature"`
+	Result    replaygain.Result `json:"result"`
+}
+
+// computeReplayGain decodes a WAV file in full and runs the
+// ReplayGain analysis over it. Only WAV sources can be analyzed today
+// since that's the only format the server decodes to PCM.
+func computeReplayGain(filePath string) (replaygain.Result, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return replaygain.Result{}, err
+	}
+	defer file.Close()
+
+	info, err := readWavHeader(file)
+	if err != nil {
+		return replaygain.Result{}, err
+	}
+
+	var pcm []int16
+	chunk := make([]int16, 65536)
+	for {
+		n, err := readPCMSamples(file, chunk)
+		if n > 0 {
+			pcm = append(pcm, chunk[:n]...)
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	return replaygain.Analyze(pcm, info.SampleRate, info.Channels), nil
+}
+
+// statSignature returns a cheap identifier for filePath's current
+// contents, derived from its size and modification time rather than
+// a full read, used as the ReplayGain cache key.
+func statSignature(filePath string) (string, error) {
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d-%d", fi.Size(), fi.ModTime().UnixNano()), nil
+}
+
+// prescanReplayGain analyzes every WAV file in dir with a small
+// worker pool so the first "start" message for any of them doesn't
+// pay the full analysis cost.
+func prescanReplayGain(dir string) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		log.Println("Error reading resource directory for ReplayGain pre-scan:", err)
+		return
+	}
+
+	paths := make(chan string)
+	var wg sync.WaitGroup
+
+	for i := 0; i < replayGainPrescanWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				if _, err := loadOrComputeReplayGain(path); err != nil {
+					log.Println("Error pre-scanning ReplayGain for", path, ":", err)
+				}
+			}
+		}()
+	}
+
+	for _, file := range files {
+		if !file.IsDir() && filepath.Ext(file.Name()) == ".wav" {
+			paths <- filepath.Join(dir, file.Name())
+		}
+	}
+	close(paths)
+	wg.Wait()
+
+	log.Println("ReplayGain pre-scan complete")
+}
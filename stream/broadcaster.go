@@ -0,0 +1,154 @@
+package stream
+
+import (
+	"sync"
+)
+
+// DefaultRingCapacity holds roughly 10s of 8KB audio chunks paced at
+// 20ms each (the WebSocket handler's existing chunking), used when a
+// subscriber doesn't ask for a specific depth.
+const DefaultRingCapacity = 500
+
+// Broadcaster fans packets published by a single producer goroutine
+// out to any number of subscribers, each buffered through its own
+// bounded ring so one slow client can't block or slow down the rest.
+type Broadcaster struct {
+	mu          sync.Mutex
+	subscribers map[*subscription]struct{}
+}
+
+// NewBroadcaster creates an empty Broadcaster.
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{subscribers: make(map[*subscription]struct{})}
+}
+
+type subscription struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	ring      *ring
+	keepMode  KeepMode
+	out       chan Packet
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Subscribe registers a new listener with the given KeepMode and ring
+// capacity (use DefaultRingCapacity when unsure). It returns a channel
+// of packets and an unsubscribe function the caller must invoke
+// exactly once when it's done listening. The channel is closed either
+// when unsubscribe is called or when the subscriber is disconnected
+// for falling behind (KeepAll only).
+func (b *Broadcaster) Subscribe(keepMode KeepMode, capacity int) (<-chan Packet, func()) {
+	return b.SubscribeWithPrebuffer(keepMode, capacity, nil)
+}
+
+// SubscribeWithPrebuffer is like Subscribe but seeds the subscriber's
+// ring with prebuffer before any live packets arrive, so a
+// newly-joined client gets a fast-start burst instead of waiting for
+// the next real-time publish.
+func (b *Broadcaster) SubscribeWithPrebuffer(keepMode KeepMode, capacity int, prebuffer []Packet) (<-chan Packet, func()) {
+	if capacity <= 0 {
+		capacity = DefaultRingCapacity
+	}
+	if capacity < len(prebuffer) {
+		capacity = len(prebuffer)
+	}
+
+	sub := &subscription{
+		ring:     newRing(capacity),
+		keepMode: keepMode,
+		out:      make(chan Packet, 1),
+		done:     make(chan struct{}),
+	}
+	sub.cond = sync.NewCond(&sub.mu)
+
+	for _, p := range prebuffer {
+		sub.ring.pushKeepAll(p)
+	}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+
+	go sub.pump()
+
+	unsubscribe := func() { b.remove(sub) }
+	return sub.out, unsubscribe
+}
+
+// Publish delivers a packet to every current subscriber. A KeepAll
+// subscriber whose ring is already full is disconnected instead of
+// blocking the caller.
+func (b *Broadcaster) Publish(p Packet) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subscribers {
+		sub.mu.Lock()
+		switch sub.keepMode {
+		case KeepAll:
+			if !sub.ring.pushKeepAll(p) {
+				sub.mu.Unlock()
+				sub.disconnect()
+				delete(b.subscribers, sub)
+				continue
+			}
+		case KeepLast:
+			sub.ring.pushKeepLast(p)
+		}
+		sub.cond.Signal()
+		sub.mu.Unlock()
+	}
+}
+
+func (b *Broadcaster) remove(sub *subscription) {
+	b.mu.Lock()
+	_, ok := b.subscribers[sub]
+	delete(b.subscribers, sub)
+	b.mu.Unlock()
+
+	if ok {
+		sub.disconnect()
+	}
+}
+
+// disconnect stops the pump goroutine and closes out. Safe to call
+// more than once.
+func (sub *subscription) disconnect() {
+	sub.closeOnce.Do(func() {
+		close(sub.done)
+		sub.mu.Lock()
+		sub.cond.Signal()
+		sub.mu.Unlock()
+	})
+}
+
+// pump drains the ring into out until the subscriber is disconnected.
+func (sub *subscription) pump() {
+	defer close(sub.out)
+
+	for {
+		sub.mu.Lock()
+		for sub.ring.size == 0 {
+			select {
+			case <-sub.done:
+				sub.mu.Unlock()
+				return
+			default:
+			}
+			sub.cond.Wait()
+		}
+		p, ok := sub.ring.pop()
+		sub.mu.Unlock()
+
+		if !ok {
+			continue
+		}
+
+		select {
+		case sub.out <- p:
+		case <-sub.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,23 @@
+// Package stream fans a single producer's audio packets out to many
+// subscribers without re-reading the source file per listener.
+package stream
+
+// KeepMode controls what a subscriber's ring buffer does when it
+// fills up.
+type KeepMode int
+
+const (
+	// KeepAll never drops a packet; if the ring is full the
+	// subscriber is considered too slow and is disconnected.
+	KeepAll KeepMode = iota
+	// KeepLast drops the oldest buffered packet to make room for the
+	// newest one, suited to state like now-playing metadata where
+	// only the latest value matters.
+	KeepLast
+)
+
+// Packet is a single unit of fanned-out data, e.g. one chunk of
+// encoded audio.
+type Packet struct {
+	Data []byte
+}
@@ -0,0 +1,47 @@
+package stream
+
+// ring is a fixed-capacity circular buffer of packets used to
+// decouple a slow subscriber from the broadcaster's publish loop.
+type ring struct {
+	buf  []Packet
+	head int
+	size int
+}
+
+func newRing(capacity int) *ring {
+	return &ring{buf: make([]Packet, capacity)}
+}
+
+// pushKeepAll appends p, reporting false (without modifying the ring)
+// if it is already full.
+func (r *ring) pushKeepAll(p Packet) bool {
+	if r.size == len(r.buf) {
+		return false
+	}
+	r.buf[(r.head+r.size)%len(r.buf)] = p
+	r.size++
+	return true
+}
+
+// pushKeepLast appends p, discarding the oldest buffered packet first
+// if the ring is full.
+func (r *ring) pushKeepLast(p Packet) {
+	if r.size == len(r.buf) {
+		r.head = (r.head + 1) % len(r.buf)
+		r.size--
+	}
+	r.buf[(r.head+r.size)%len(r.buf)] = p
+	r.size++
+}
+
+// pop removes and returns the oldest packet, reporting false if the
+// ring is empty.
+func (r *ring) pop() (Packet, bool) {
+	if r.size == 0 {
+		return Packet{}, false
+	}
+	p := r.buf[r.head]
+	r.head = (r.head + 1) % len(r.buf)
+	r.size--
+	return p, true
+}
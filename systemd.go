@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// listenFDsStart is the first inherited file descriptor systemd
+// passes to a socket-activated process, per the sd_listen_fds(3)
+// convention.
+const listenFDsStart = 3
+
+// systemdListener returns the listener systemd has already bound and
+// handed to this process via socket activation, or nil if the
+// process wasn't started that way (LISTEN_PID/LISTEN_FDS unset or not
+// addressed to this process).
+func systemdListener() (net.Listener, error) {
+	pidStr := os.Getenv("LISTEN_PID")
+	fdsStr := os.Getenv("LISTEN_FDS")
+	if pidStr == "" || fdsStr == "" {
+		return nil, nil
+	}
+
+	pid, err := strconv.Atoi(pidStr)
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+
+	fds, err := strconv.Atoi(fdsStr)
+	if err != nil || fds < 1 {
+		return nil, fmt.Errorf("systemd: invalid LISTEN_FDS %q", fdsStr)
+	}
+
+	// We only ever advertise a single socket, so only the first
+	// inherited fd is relevant.
+	file := os.NewFile(uintptr(listenFDsStart), "systemd-socket")
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("systemd: wrapping inherited fd: %w", err)
+	}
+	return listener, nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+
+	"github.com/dangquyitt/go-audio-streaming/encoder"
+	"github.com/dangquyitt/go-audio-streaming/replaygain"
+	"github.com/gorilla/websocket"
+)
+
+// defaultTranscodeFormat and defaultTranscodeBitrate are used when a
+// client's "start" message omits format/bitrate.
+const (
+	defaultTranscodeFormat  = "mp3"
+	defaultTranscodeBitrate = 128
+)
+
+// transcodeSamplesPerRead is the number of PCM samples (per channel)
+// decoded from the source file on each pass before being encoded.
+const transcodeSamplesPerRead = 4096
+
+// streamTranscoded decodes a WAV source and re-encodes it to the
+// client's requested format, pacing writes to the source sample rate.
+func streamTranscoded(client *Client, file *os.File, filename string, format string, bitrateKbps int, targetLUFS float64) {
+	if format == "" {
+		format = defaultTranscodeFormat
+	}
+	if bitrateKbps == 0 {
+		bitrateKbps = defaultTranscodeBitrate
+	}
+	if targetLUFS == 0 {
+		targetLUFS = defaultTargetLUFS
+	}
+
+	info, err := readWavHeader(file)
+	if err != nil {
+		log.Println("Error reading WAV header:", err)
+		sendStatusMessage(client, "Error: Unsupported or malformed WAV file")
+		websocketErrors.Inc()
+		client.streaming = false
+		return
+	}
+
+	var gainDB float64
+	if rg, err := loadOrComputeReplayGain(file.Name()); err != nil {
+		log.Println("Error computing ReplayGain, streaming unnormalized:", err)
+	} else {
+		gainDB = replaygain.GainForTarget(rg, targetLUFS)
+	}
+
+	enc, err := encoder.New(encoder.Format(format), encoder.Options{
+		SampleRate:  info.SampleRate,
+		Channels:    info.Channels,
+		BitrateKbps: bitrateKbps,
+	})
+	if err != nil {
+		log.Println("Error creating encoder:", err)
+		sendStatusMessage(client, "Error: Unsupported output format")
+		websocketErrors.Inc()
+		client.streaming = false
+		return
+	}
+	defer flushEncoder(client, enc, filename)
+
+	sendFormatMessage(client, format, info.SampleRate, info.Channels)
+	sendStatusMessage(client, "Streaming "+filename)
+
+	pcm := make([]int16, transcodeSamplesPerRead*info.Channels)
+
+	for {
+		select {
+		case <-client.stopCh:
+			log.Printf("Streaming stopped for file: %s", filename)
+			return
+		default:
+			n, readErr := readPCMSamples(file, pcm)
+			if n > 0 {
+				toEncode := pcm[:n]
+				if gainDB != 0 {
+					toEncode = replaygain.ApplyGain(toEncode, gainDB)
+				}
+
+				encoded, encErr := enc.Encode(toEncode)
+				if encErr != nil {
+					log.Println("Error encoding audio:", encErr)
+					sendStatusMessage(client, "Error encoding audio")
+					websocketErrors.Inc()
+					client.streaming = false
+					return
+				}
+
+				if len(encoded) > 0 {
+					client.mu.Lock()
+					writeErr := client.conn.WriteMessage(websocket.BinaryMessage, encoded)
+					client.mu.Unlock()
+
+					if writeErr != nil {
+						log.Println("Error writing to WebSocket:", writeErr)
+						websocketErrors.Inc()
+						client.streaming = false
+						return
+					}
+
+					audioChunksSent.Inc()
+					client.totalBytes += len(encoded)
+				}
+
+				frames := n / info.Channels
+				time.Sleep(time.Duration(frames) * time.Second / time.Duration(info.SampleRate))
+			}
+
+			if readErr != nil {
+				log.Printf("Finished streaming file: %s", filename)
+				sendStatusMessage(client, "Streaming finished")
+
+				duration := time.Since(client.startTime).Seconds()
+				audioStreamDuration.Observe(duration)
+
+				client.streaming = false
+				return
+			}
+		}
+	}
+}
+
+// flushEncoder closes enc and writes any final bytes it flushes (e.g.
+// a padded last Opus frame) to client before the stream ends.
+func flushEncoder(client *Client, enc encoder.Encoder, filename string) {
+	final, err := enc.Close()
+	if err != nil {
+		log.Println("Error closing encoder:", err)
+		websocketErrors.Inc()
+		return
+	}
+	if len(final) == 0 {
+		return
+	}
+
+	client.mu.Lock()
+	writeErr := client.conn.WriteMessage(websocket.BinaryMessage, final)
+	client.mu.Unlock()
+
+	if writeErr != nil {
+		log.Println("Error writing to WebSocket:", writeErr)
+		websocketErrors.Inc()
+		return
+	}
+
+	audioChunksSent.Inc()
+	client.totalBytes += len(final)
+}
+
+// sendFormatMessage announces the negotiated codec to the client
+// before the first encoded frame is sent.
+func sendFormatMessage(client *Client, codec string, sampleRate int, channels int) {
+	msg := FormatMessage{
+		Type:       "format",
+		Codec:      codec,
+		SampleRate: sampleRate,
+		Channels:   channels,
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Println("Error marshaling format message:", err)
+		websocketErrors.Inc()
+		return
+	}
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	if err := client.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		log.Println("Error sending format message:", err)
+		websocketErrors.Inc()
+	}
+}
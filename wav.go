@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// wavInfo describes the PCM layout of a WAV file, as needed to feed
+// samples into an encoder.Encoder.
+type wavInfo struct {
+	SampleRate int
+	Channels   int
+	DataSize   uint32
+}
+
+// wavFormatPCM is the "fmt " chunk's audioFormat value for integer
+// linear PCM, the only layout readPCMSamples knows how to decode.
+const wavFormatPCM = 1
+
+// wavBitsPerSamplePCM is the only sample width readPCMSamples
+// supports; a file reporting anything else (8-bit, 24-bit, float)
+// would be silently misdecoded if it weren't rejected here.
+const wavBitsPerSamplePCM = 16
+
+// readWavHeader parses a canonical RIFF/WAVE header from r, leaving
+// the reader positioned at the start of the "data" chunk.
+func readWavHeader(r io.Reader) (wavInfo, error) {
+	var info wavInfo
+
+	var riffHeader [12]byte
+	if _, err := io.ReadFull(r, riffHeader[:]); err != nil {
+		return info, fmt.Errorf("wav: reading RIFF header: %w", err)
+	}
+	if string(riffHeader[0:4]) != "RIFF" || string(riffHeader[8:12]) != "WAVE" {
+		return info, fmt.Errorf("wav: not a RIFF/WAVE file")
+	}
+
+	for {
+		var chunkHeader [8]byte
+		if _, err := io.ReadFull(r, chunkHeader[:]); err != nil {
+			return info, fmt.Errorf("wav: reading chunk header: %w", err)
+		}
+		chunkID := string(chunkHeader[0:4])
+		chunkSize := binary.LittleEndian.Uint32(chunkHeader[4:8])
+
+		switch chunkID {
+		case "fmt ":
+			fmtBody := make([]byte, chunkSize)
+			if _, err := io.ReadFull(r, fmtBody); err != nil {
+				return info, fmt.Errorf("wav: reading fmt chunk: %w", err)
+			}
+			if len(fmtBody) < 16 {
+				return info, fmt.Errorf("wav: fmt chunk too short")
+			}
+
+			audioFormat := binary.LittleEndian.Uint16(fmtBody[0:2])
+			bitsPerSample := binary.LittleEndian.Uint16(fmtBody[14:16])
+			if audioFormat != wavFormatPCM || bitsPerSample != wavBitsPerSamplePCM {
+				return info, fmt.Errorf("wav: unsupported format %d/%d-bit, only 16-bit integer PCM is supported", audioFormat, bitsPerSample)
+			}
+
+			info.Channels = int(binary.LittleEndian.Uint16(fmtBody[2:4]))
+			info.SampleRate = int(binary.LittleEndian.Uint32(fmtBody[4:8]))
+			if info.Channels == 0 || info.SampleRate == 0 {
+				return info, fmt.Errorf("wav: invalid fmt chunk, channels=%d sample rate=%d", info.Channels, info.SampleRate)
+			}
+
+		case "data":
+			info.DataSize = chunkSize
+			return info, nil
+
+		default:
+			if _, err := io.CopyN(io.Discard, r, int64(chunkSize)); err != nil {
+				return info, fmt.Errorf("wav: skipping chunk %q: %w", chunkID, err)
+			}
+		}
+	}
+}
+
+// readPCMSamples reads up to len(out) int16 samples of interleaved
+// PCM from r, returning the number of samples read.
+func readPCMSamples(r io.Reader, out []int16) (int, error) {
+	raw := make([]byte, len(out)*2)
+	n, err := io.ReadFull(r, raw)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return 0, err
+	}
+
+	samples := n / 2
+	for i := 0; i < samples; i++ {
+		out[i] = int16(binary.LittleEndian.Uint16(raw[2*i : 2*i+2]))
+	}
+	if err == io.ErrUnexpectedEOF {
+		return samples, io.EOF
+	}
+	return samples, nil
+}